@@ -0,0 +1,193 @@
+package anchor
+
+import (
+	"AnchorService/common"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/FactomProject/factomd/anchor"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func init() {
+	Register("btc", func() Backend { return NewAnchorBTC() })
+}
+
+// AnchorBTC anchors directory block hashes to Bitcoin via a full node's
+// JSON-RPC interface.
+type AnchorBTC struct {
+	service   *AnchorService
+	rpcClient *rpcclient.Client
+}
+
+func NewAnchorBTC() *AnchorBTC {
+	return new(AnchorBTC)
+}
+
+func (btc *AnchorBTC) Name() string {
+	return "btc"
+}
+
+func (btc *AnchorBTC) Init(cfg *common.AnchorBackendConfig, svc *AnchorService) error {
+	btc.service = svc
+	return btc.InitRPCClient(cfg)
+}
+
+// InitRPCClient connects to the configured bitcoind/btcd node. It is kept
+// as its own method, rather than folded into Init, because it's also handy
+// to call directly from tests/tools that only need the RPC connection.
+func (btc *AnchorBTC) InitRPCClient(cfg *common.AnchorBackendConfig) error {
+	connCfg := &rpcclient.ConnConfig{
+		Host:         cfg.RPCHost,
+		User:         cfg.RPCUser,
+		Pass:         cfg.RPCPassword,
+		HTTPPostMode: true,
+		DisableTLS:   !cfg.RPCTLS,
+	}
+
+	client, err := rpcclient.New(connCfg, nil)
+	if err != nil {
+		return fmt.Errorf("connecting to btc RPC: %w", err)
+	}
+
+	btc.rpcClient = client
+	return nil
+}
+
+func (btc *AnchorBTC) Close() error {
+	if btc.rpcClient != nil {
+		btc.rpcClient.Shutdown()
+	}
+	return nil
+}
+
+// PlaceAnchor broadcasts msg.DBMerkleRoot (already framed by worker/
+// flushBatch via PrependBlockHeight/PrependBatchHeader) as an OP_RETURN
+// transaction, then writes the resulting txid to the anchor chain so
+// GetAnchorProof-style verification can point back at the Bitcoin
+// transaction that carries it. A broadcast or anchor-chain write failure is
+// reported on AnchorFail so dispatch retries it with backoff instead of
+// silently dropping the height.
+func (btc *AnchorBTC) PlaceAnchor(msg common.DirectoryBlockAnchorInfo) {
+	log.Info("placing btc anchor", "height", msg.DBHeight)
+
+	txid, err := btc.broadcastOpReturn(msg.DBMerkleRoot)
+	if err != nil {
+		log.Error("could not broadcast btc anchor transaction", "height", msg.DBHeight, "error", err)
+		btc.service.AnchorFail <- msg
+		return
+	}
+
+	rec := &anchor.AnchorRecord{
+		AnchorRecordVer: 1,
+		DBHeight:        msg.DBHeight,
+		KeyMR:           hex.EncodeToString(msg.DBMerkleRoot),
+		Bitcoin: &anchor.BitcoinStruct{
+			TransactionHash: txid,
+		},
+	}
+
+	if err := btc.service.submitEntryToAnchorChain(rec); err != nil {
+		log.Error("could not write btc anchor record to anchor chain", "height", msg.DBHeight, "txid", txid, "error", err)
+		btc.service.AnchorFail <- msg
+	}
+}
+
+// broadcastOpReturn funds, signs, and sends a zero-value OP_RETURN
+// transaction carrying payload via the node's wallet. fundrawtransaction
+// and signrawtransactionwithwallet are wallet RPCs with no typed method on
+// rpcclient.Client, so they're called through RawRequest the same way any
+// bitcoind-only RPC would be.
+func (btc *AnchorBTC) broadcastOpReturn(payload []byte) (string, error) {
+	script, err := txscript.NullDataScript(payload)
+	if err != nil {
+		return "", fmt.Errorf("building OP_RETURN script: %w", err)
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxOut(wire.NewTxOut(0, script))
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("serializing OP_RETURN transaction: %w", err)
+	}
+
+	fundedHex, err := btc.rawRequestHex("fundrawtransaction", hex.EncodeToString(buf.Bytes()))
+	if err != nil {
+		return "", fmt.Errorf("fundrawtransaction: %w", err)
+	}
+
+	signedHex, complete, err := btc.signRawTransaction(fundedHex)
+	if err != nil {
+		return "", fmt.Errorf("signrawtransactionwithwallet: %w", err)
+	}
+	if !complete {
+		return "", errors.New("wallet could not fully sign the OP_RETURN transaction")
+	}
+
+	return btc.sendRawTransaction(signedHex)
+}
+
+func (btc *AnchorBTC) rawRequestHex(method, rawHex string) (string, error) {
+	params, err := json.Marshal(rawHex)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := btc.rpcClient.RawRequest(method, []json.RawMessage{params})
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Hex string `json:"hex"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return "", fmt.Errorf("parsing %s response: %w", method, err)
+	}
+	return result.Hex, nil
+}
+
+func (btc *AnchorBTC) signRawTransaction(rawHex string) (signedHex string, complete bool, err error) {
+	params, err := json.Marshal(rawHex)
+	if err != nil {
+		return "", false, err
+	}
+
+	resp, err := btc.rpcClient.RawRequest("signrawtransactionwithwallet", []json.RawMessage{params})
+	if err != nil {
+		return "", false, err
+	}
+
+	var result struct {
+		Hex      string `json:"hex"`
+		Complete bool   `json:"complete"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return "", false, fmt.Errorf("parsing signrawtransactionwithwallet response: %w", err)
+	}
+	return result.Hex, result.Complete, nil
+}
+
+func (btc *AnchorBTC) sendRawTransaction(rawHex string) (string, error) {
+	params, err := json.Marshal(rawHex)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := btc.rpcClient.RawRequest("sendrawtransaction", []json.RawMessage{params})
+	if err != nil {
+		return "", err
+	}
+
+	var txid string
+	if err := json.Unmarshal(resp, &txid); err != nil {
+		return "", fmt.Errorf("parsing sendrawtransaction response: %w", err)
+	}
+	return txid, nil
+}
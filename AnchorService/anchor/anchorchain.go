@@ -0,0 +1,104 @@
+package anchor
+
+import (
+	"AnchorService/common"
+	"AnchorService/util"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/FactomProject/factom"
+)
+
+// ErrInsufficientEntryCredits is returned when the configured EC address
+// can't cover the entry credit cost of the anchor chain's commit.
+var ErrInsufficientEntryCredits = errors.New("EC address does not have enough entry credits to create the anchor chain")
+
+// isChainNotFoundErr reports whether err from factom.GetChainHead means the
+// chain genuinely doesn't exist on factomd yet, as opposed to a transient
+// transport/server failure. Only the former should fall through to minting
+// a new chain - treating a flaky factomd the same way would mint (and burn
+// EC on) an orphan chain next to the real one every time startup races a
+// blip.
+func isChainNotFoundErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "not found") || strings.Contains(msg, "missing chain head")
+}
+
+// bootstrapAnchorChain makes sure the configured anchor chain exists on
+// factomd. If cfg.Anchor.AnchorChainID is empty, or names a chain factomd
+// doesn't know about yet, a brand new chain is created around a seed entry
+// and its ChainID is written back to cfg and persisted to disk so restarts
+// reuse it instead of minting a new chain every time.
+func bootstrapAnchorChain(cfg *common.Config, service *AnchorService) (*common.Hash, error) {
+	if cfg.Anchor.AnchorChainID != "" {
+		if chainID, err := common.HexToHash(cfg.Anchor.AnchorChainID); err == nil && chainID != nil {
+			if _, err := factom.GetChainHead(chainID.String()); err == nil {
+				return chainID, nil
+			} else if !isChainNotFoundErr(err) {
+				return nil, fmt.Errorf("checking for existing anchor chain %s on factomd: %w", chainID.String(), err)
+			}
+			log.Info("configured anchor chain not found on factomd, creating it", "chainid", chainID.String())
+		} else {
+			log.Info("no usable AnchorChainID in configuration, creating a new anchor chain")
+		}
+	}
+
+	seed := factom.NewEntry()
+	seed.ExtIDs = [][]byte{
+		[]byte("AnchorService"),
+		[]byte(cfg.App.Network),
+		[]byte(service.serverECKey.PubBytes()),
+	}
+	seed.Content = []byte(fmt.Sprintf("anchor chain created %s", time.Now().UTC().Format(time.RFC3339)))
+
+	chain, err := factom.NewChain(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := factom.ComposeChainCommit(chain, service.serverECKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), service.factomdTimeout)
+	defer cancel()
+
+	if _, err := service.postV2(ctx, "commit-chain", commit); err != nil {
+		var rpcErr *FactomdRPCError
+		if errors.As(err, &rpcErr) && (strings.Contains(rpcErr.Error(), "balance") || strings.Contains(rpcErr.Error(), "entry credit")) {
+			return nil, ErrInsufficientEntryCredits
+		}
+		return nil, err
+	}
+
+	time.Sleep(2 * time.Second)
+
+	reveal, err := factom.ComposeChainReveal(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := service.postV2(ctx, "reveal-chain", reveal); err != nil {
+		return nil, err
+	}
+
+	chainID, err := common.HexToHash(chain.ChainID)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Anchor.AnchorChainID = chain.ChainID
+	if err := util.SaveConfig(cfg); err != nil {
+		log.Error("anchor chain created but could not persist AnchorChainID to config", "chainid", chain.ChainID, "error", err)
+	}
+
+	log.Info("created new anchor chain", "chainid", chain.ChainID)
+	return chainID, nil
+}
@@ -0,0 +1,101 @@
+package anchor
+
+import (
+	"AnchorService/common"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/FactomProject/factomd/anchor"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func init() {
+	Register("eth", func() Backend { return NewAnchorETH() })
+}
+
+// AnchorETH anchors directory block hashes to Ethereum by sending a
+// zero-value transaction whose data field carries the anchor payload,
+// Ethereum's analogue of Bitcoin's OP_RETURN. AnchorEVM is the
+// contract-call equivalent for chains that anchor through a deployed
+// contract instead.
+type AnchorETH struct {
+	service       *AnchorService
+	ethClient     *ethclient.Client
+	privateKey    *ecdsa.PrivateKey
+	from          ethcommon.Address
+	anchorAddress ethcommon.Address
+}
+
+func NewAnchorETH() *AnchorETH {
+	return new(AnchorETH)
+}
+
+func (eth *AnchorETH) Name() string {
+	return "eth"
+}
+
+func (eth *AnchorETH) Init(cfg *common.AnchorBackendConfig, svc *AnchorService) error {
+	eth.service = svc
+
+	privateKey, err := crypto.HexToECDSA(cfg.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("parsing eth private key: %w", err)
+	}
+	eth.privateKey = privateKey
+	eth.from = crypto.PubkeyToAddress(privateKey.PublicKey)
+	eth.anchorAddress = ethcommon.HexToAddress(cfg.AnchorAddress)
+
+	return eth.InitEthClient(cfg)
+}
+
+// InitEthClient dials the configured RPC endpoint for the Ethereum node
+// this backend anchors to.
+func (eth *AnchorETH) InitEthClient(cfg *common.AnchorBackendConfig) error {
+	client, err := ethclient.Dial(cfg.RPCURL)
+	if err != nil {
+		return fmt.Errorf("dialing eth RPC: %w", err)
+	}
+
+	eth.ethClient = client
+	return nil
+}
+
+func (eth *AnchorETH) Close() error {
+	if eth.ethClient != nil {
+		eth.ethClient.Close()
+	}
+	return nil
+}
+
+// PlaceAnchor broadcasts msg.DBMerkleRoot (already framed by worker/
+// flushBatch via PrependBlockHeight/PrependBatchHeader) to the configured
+// anchor address, then writes the resulting tx hash to the anchor chain.
+// A broadcast or anchor-chain write failure is reported on AnchorFail so
+// dispatch retries it with backoff instead of silently dropping the height.
+func (eth *AnchorETH) PlaceAnchor(msg common.DirectoryBlockAnchorInfo) {
+	log.Info("placing eth anchor", "height", msg.DBHeight)
+
+	txHash, err := sendEVMTransaction(eth.ethClient, eth.privateKey, eth.from, eth.anchorAddress, msg.DBMerkleRoot)
+	if err != nil {
+		log.Error("could not broadcast eth anchor transaction", "height", msg.DBHeight, "error", err)
+		eth.service.AnchorFail <- msg
+		return
+	}
+
+	rec := &anchor.AnchorRecord{
+		AnchorRecordVer: 1,
+		DBHeight:        msg.DBHeight,
+		KeyMR:           hex.EncodeToString(msg.DBMerkleRoot),
+		Ethereum: &anchor.EthereumStruct{
+			TXID: txHash.Hex(),
+		},
+	}
+
+	if err := eth.service.submitEntryToAnchorChain(rec); err != nil {
+		log.Error("could not write eth anchor record to anchor chain", "height", msg.DBHeight, "txid", txHash.Hex(), "error", err)
+		eth.service.AnchorFail <- msg
+	}
+}
@@ -0,0 +1,119 @@
+package anchor
+
+import (
+	"AnchorService/common"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/FactomProject/factomd/anchor"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+func init() {
+	Register("evm", func() Backend { return NewAnchorEVM() })
+}
+
+// AnchorEVM is a generic, config-driven backend for any EVM-compatible
+// chain (XDC, BSC, Polygon, ...): an RPC URL, a deployed anchor contract
+// address, and the ABI of the single method it calls to record a root
+// hash. New chains only need a config entry, not a new Go file.
+type AnchorEVM struct {
+	service    *AnchorService
+	client     *ethclient.Client
+	privateKey *ecdsa.PrivateKey
+	from       ethcommon.Address
+	contract   ethcommon.Address
+	method     string
+	abi        abi.ABI
+}
+
+func NewAnchorEVM() *AnchorEVM {
+	return new(AnchorEVM)
+}
+
+func (evm *AnchorEVM) Name() string {
+	return "evm"
+}
+
+func (evm *AnchorEVM) Init(cfg *common.AnchorBackendConfig, svc *AnchorService) error {
+	client, err := ethclient.Dial(cfg.RPCURL)
+	if err != nil {
+		return fmt.Errorf("dialing evm RPC: %w", err)
+	}
+
+	parsedABI, err := abi.JSON(strings.NewReader(cfg.ContractABI))
+	if err != nil {
+		return fmt.Errorf("parsing evm contract ABI: %w", err)
+	}
+
+	if _, ok := parsedABI.Methods[cfg.ContractMethod]; !ok {
+		return fmt.Errorf("evm contract ABI has no method %q", cfg.ContractMethod)
+	}
+
+	privateKey, err := crypto.HexToECDSA(cfg.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("parsing evm private key: %w", err)
+	}
+
+	evm.service = svc
+	evm.client = client
+	evm.privateKey = privateKey
+	evm.from = crypto.PubkeyToAddress(privateKey.PublicKey)
+	evm.contract = ethcommon.HexToAddress(cfg.ContractAddress)
+	evm.method = cfg.ContractMethod
+	evm.abi = parsedABI
+
+	return nil
+}
+
+func (evm *AnchorEVM) Close() error {
+	if evm.client != nil {
+		evm.client.Close()
+	}
+	return nil
+}
+
+// PlaceAnchor packs msg.DBMerkleRoot (already framed by worker/flushBatch
+// via PrependBlockHeight/PrependBatchHeader) into a call to the configured
+// contract method, broadcasts it, and writes the resulting tx hash to the
+// anchor chain. A pack/broadcast/anchor-chain-write failure is reported on
+// AnchorFail so dispatch retries it with backoff instead of silently
+// dropping the height.
+func (evm *AnchorEVM) PlaceAnchor(msg common.DirectoryBlockAnchorInfo) {
+	log.Info("placing evm anchor", "chain", evm.Name(), "contract", evm.contract.Hex(), "height", msg.DBHeight)
+
+	data, err := evm.abi.Pack(evm.method, big.NewInt(int64(msg.DBHeight)), msg.DBMerkleRoot)
+	if err != nil {
+		log.Error("could not pack evm anchor call", "error", err)
+		evm.service.AnchorFail <- msg
+		return
+	}
+
+	txHash, err := sendEVMTransaction(evm.client, evm.privateKey, evm.from, evm.contract, data)
+	if err != nil {
+		log.Error("could not broadcast evm anchor transaction", "height", msg.DBHeight, "error", err)
+		evm.service.AnchorFail <- msg
+		return
+	}
+
+	rec := &anchor.AnchorRecord{
+		AnchorRecordVer: 1,
+		DBHeight:        msg.DBHeight,
+		KeyMR:           hex.EncodeToString(msg.DBMerkleRoot),
+		Ethereum: &anchor.EthereumStruct{
+			ContractAddress: evm.contract.Hex(),
+			TXID:            txHash.Hex(),
+		},
+	}
+
+	if err := evm.service.submitEntryToAnchorChain(rec); err != nil {
+		log.Error("could not write evm anchor record to anchor chain", "height", msg.DBHeight, "txid", txHash.Hex(), "error", err)
+		evm.service.AnchorFail <- msg
+	}
+}
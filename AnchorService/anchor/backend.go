@@ -0,0 +1,60 @@
+package anchor
+
+import (
+	"AnchorService/common"
+	"fmt"
+	"sync"
+)
+
+// Backend is an anchor target (BTC, ETH, an EVM sidechain, ...). It extends
+// Anchor with the lifecycle hooks NewAnchorService needs to wire a backend
+// up from configuration without knowing its concrete type.
+type Backend interface {
+	Anchor
+
+	// Name identifies the backend, matching the name it was Registered
+	// under and the value operators put in cfg.App.AnchorTo.
+	Name() string
+
+	// Init wires the backend up to its chain (RPC client, wallet, etc.)
+	// using its slice of the Anchor config section, and gives it a back
+	// reference to the owning AnchorService the way the BTC/ETH backends
+	// already do via their `service` field.
+	Init(cfg *common.AnchorBackendConfig, svc *AnchorService) error
+
+	// Close releases any resources acquired by Init (RPC connections,
+	// file handles, ...). It is called when the AnchorService stops.
+	Close() error
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]func() Backend)
+)
+
+// Register adds a backend factory to the registry under name. Backend
+// implementations call this from an init() in their own file, e.g.
+// anchorbtc.go registering "btc", so that adding a new chain never
+// requires touching NewAnchorService.
+func Register(name string, factory func() Backend) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("anchor: backend %q already registered", name))
+	}
+	registry[name] = factory
+}
+
+// Lookup returns a fresh instance of the backend registered under name, if
+// any.
+func Lookup(name string) (Backend, bool) {
+	registryMu.Lock()
+	factory, ok := registry[name]
+	registryMu.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
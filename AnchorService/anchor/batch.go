@@ -0,0 +1,374 @@
+package anchor
+
+import (
+	"AnchorService/common"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	anchorProofsFile     = "anchor_proofs.json"
+	pendingBatchFile     = "anchor_pending_batch.json"
+	defaultBatchInterval = 10 * time.Minute
+)
+
+// MerkleProof lets a downstream verifier reconstruct that a single
+// directory block's leaf was included under a batch's Merkle root: walk up
+// from LeafIndex, hashing with each Siblings entry in order.
+type MerkleProof struct {
+	LeafIndex int      `json:"leaf_index"`
+	Siblings  [][]byte `json:"siblings"`
+}
+
+// BatchAnchorRecord is the content written to the anchor chain for a batch:
+// the Merkle root covering [MinHeight, MaxHeight] plus every leaf's proof,
+// keyed by directory block height so GetAnchorProof can answer for any
+// height in the batch without recomputing the tree.
+type BatchAnchorRecord struct {
+	MinHeight uint32                  `json:"min_height"`
+	MaxHeight uint32                  `json:"max_height"`
+	Root      []byte                  `json:"root"`
+	Proofs    map[uint32]*MerkleProof `json:"proofs"`
+}
+
+// PrependBatchHeader generalizes PrependBlockHeight to a height range: the
+// 'F','a' marker followed by the 6-byte minimum and maximum directory
+// block heights covered by root.
+func PrependBatchHeader(minHeight, maxHeight uint32, root []byte) ([]byte, error) {
+	minBytes, err := packHeight(minHeight)
+	if err != nil {
+		return nil, err
+	}
+	maxBytes, err := packHeight(maxHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	header := []byte{'F', 'a'}
+	header = append(header, minBytes...)
+	header = append(header, maxBytes...)
+	header = append(header, root...)
+	return header, nil
+}
+
+func packHeight(height uint32) ([]byte, error) {
+	h := uint64(height)
+	if 0xFFFFFFFFFFFF&h != h {
+		return nil, errors.New("bad block height")
+	}
+
+	big := make([]byte, 8)
+	binary.BigEndian.PutUint64(big, h)
+	return big[2:8], nil
+}
+
+// buildMerkleTree hashes leaves pairwise (duplicating the last leaf on an
+// odd level, the common convention) and returns the root along with every
+// intermediate level so proofs can be derived for each leaf.
+func buildMerkleTree(leaves [][]byte) (root []byte, levels [][][]byte) {
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		sum := sha256.Sum256(leaf)
+		level[i] = sum[:]
+	}
+	levels = append(levels, level)
+
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			left := level[i]
+			right := left
+			if i+1 < len(level) {
+				right = level[i+1]
+			}
+			sum := sha256.Sum256(append(append([]byte{}, left...), right...))
+			next = append(next, sum[:])
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return level[0], levels
+}
+
+// proofFor derives leafIndex's inclusion proof from the levels produced by
+// buildMerkleTree.
+func proofFor(leafIndex int, levels [][][]byte) *MerkleProof {
+	proof := &MerkleProof{LeafIndex: leafIndex}
+
+	index := leafIndex
+	for _, level := range levels[:len(levels)-1] {
+		siblingIndex := index ^ 1
+		if siblingIndex >= len(level) {
+			siblingIndex = index
+		}
+		proof.Siblings = append(proof.Siblings, level[siblingIndex])
+		index /= 2
+	}
+
+	return proof
+}
+
+// buildBatchRecord turns an ordered batch of DirectoryBlockAnchorInfo into
+// a BatchAnchorRecord: one leaf per dir-block (PrependBlockHeight(height,
+// hash), so individual proofs stay verifiable against the existing leaf
+// format), a Merkle root over those leaves, and a proof per leaf.
+func buildBatchRecord(batch []common.DirectoryBlockAnchorInfo) (*BatchAnchorRecord, error) {
+	if len(batch) == 0 {
+		return nil, errors.New("cannot build a batch anchor record from an empty batch")
+	}
+
+	leaves := make([][]byte, len(batch))
+	for i, msg := range batch {
+		leaf, err := PrependBlockHeight(msg.DBHeight, msg.DBMerkleRoot)
+		if err != nil {
+			return nil, fmt.Errorf("building leaf for height %d: %w", msg.DBHeight, err)
+		}
+		leaves[i] = leaf
+	}
+
+	root, levels := buildMerkleTree(leaves)
+
+	rec := &BatchAnchorRecord{
+		MinHeight: batch[0].DBHeight,
+		MaxHeight: batch[0].DBHeight,
+		Root:      root,
+		Proofs:    make(map[uint32]*MerkleProof, len(batch)),
+	}
+
+	for i, msg := range batch {
+		if msg.DBHeight < rec.MinHeight {
+			rec.MinHeight = msg.DBHeight
+		}
+		if msg.DBHeight > rec.MaxHeight {
+			rec.MaxHeight = msg.DBHeight
+		}
+		rec.Proofs[msg.DBHeight] = proofFor(i, levels)
+	}
+
+	return rec, nil
+}
+
+// addToBatch appends msg to the in-flight batch and reports whether it just
+// reached batchSize, so the caller knows to flush immediately instead of
+// waiting for batchInterval.
+func (service *AnchorService) addToBatch(msg common.DirectoryBlockAnchorInfo) bool {
+	service.batchMu.Lock()
+	defer service.batchMu.Unlock()
+
+	service.pendingBatch = append(service.pendingBatch, msg)
+	return len(service.pendingBatch) >= service.batchSize
+}
+
+// flushBatch builds a Merkle-batched anchor record for whatever is
+// currently pending, anchors the root on the configured backend, and
+// writes the full record (root + per-leaf proofs) to the anchor chain. If
+// the record or its header can't be built, or the anchor chain write fails,
+// the batch is put back in pendingBatch so it's retried on the next tick
+// instead of being dropped. PlaceAnchor reports no result here: a backend
+// failure is sent on AnchorFail by the backend, and the record is still
+// written to the anchor chain.
+func (service *AnchorService) flushBatch() {
+	service.batchMu.Lock()
+	batch := service.pendingBatch
+	service.pendingBatch = nil
+	service.batchMu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	rec, err := buildBatchRecord(batch)
+	if err != nil {
+		log.Error("could not build batch anchor record", "error", err)
+		service.batchMu.Lock()
+		service.pendingBatch = append(batch, service.pendingBatch...)
+		service.batchMu.Unlock()
+		return
+	}
+
+	header, err := PrependBatchHeader(rec.MinHeight, rec.MaxHeight, rec.Root)
+	if err != nil {
+		log.Error("could not build batch header", "error", err)
+		service.batchMu.Lock()
+		service.pendingBatch = append(batch, service.pendingBatch...)
+		service.batchMu.Unlock()
+		return
+	}
+
+	service.DoAnchor.PlaceAnchor(common.DirectoryBlockAnchorInfo{
+		DBHeight:     rec.MaxHeight,
+		DBMerkleRoot: header,
+	})
+
+	if err := service.submitBatchToAnchorChain(rec); err != nil {
+		log.Error("could not submit batch anchor record to anchor chain, will retry next batch tick", "min_height", rec.MinHeight, "max_height", rec.MaxHeight, "error", err)
+		service.batchMu.Lock()
+		service.pendingBatch = append(batch, service.pendingBatch...)
+		service.batchMu.Unlock()
+		return
+	}
+
+	log.Info("flushed batch anchor record", "min_height", rec.MinHeight, "max_height", rec.MaxHeight, "count", len(batch))
+}
+
+// submitBatchToAnchorChain is submitEntryToAnchorChain's sibling for batch
+// mode: the record's Merkle root is what actually gets anchored on the
+// backend (via PlaceAnchor, using PrependBatchHeader), while the full
+// BatchAnchorRecord - root plus every leaf's proof - is what gets written
+// to the anchor chain, so GetAnchorProof can serve a proof without
+// recomputing the tree.
+func (service *AnchorService) submitBatchToAnchorChain(rec *BatchAnchorRecord) error {
+	content, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	sig := service.sigKey.Sign(content)
+	newentry := NewEntry(service.anchorChainID.String(), sig.Bytes(), content)
+	if err := service.commitAndRevealEntry(newentry); err != nil {
+		return err
+	}
+
+	service.recordBatchProof(rec)
+	return nil
+}
+
+// recordBatchProof makes rec's proofs available to GetAnchorProof and, if a
+// data dir is configured, persists them so they survive a restart.
+func (service *AnchorService) recordBatchProof(rec *BatchAnchorRecord) {
+	service.batchMu.Lock()
+	if service.batchProofs == nil {
+		service.batchProofs = make(map[uint32]*BatchAnchorRecord)
+	}
+	for height := range rec.Proofs {
+		service.batchProofs[height] = rec
+	}
+	service.batchMu.Unlock()
+
+	path := service.batchProofsFilePath()
+	if path == "" {
+		return
+	}
+	if err := service.saveBatchProofs(path); err != nil {
+		log.Error("could not persist anchor batch proofs", "path", path, "error", err)
+	}
+}
+
+// GetAnchorProof returns the serialized MerkleProof - plus the batch root
+// and height range it proves against - for a directory block height that
+// was anchored in batch mode, so a downstream verifier can reconstruct
+// that the dir-block was included in that batch's root.
+func (service *AnchorService) GetAnchorProof(height uint32) ([]byte, error) {
+	service.batchMu.Lock()
+	rec, ok := service.batchProofs[height]
+	service.batchMu.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("no batch anchor proof recorded for height %d", height)
+	}
+
+	return json.Marshal(struct {
+		MinHeight uint32       `json:"min_height"`
+		MaxHeight uint32       `json:"max_height"`
+		Root      []byte       `json:"root"`
+		Proof     *MerkleProof `json:"proof"`
+	}{
+		MinHeight: rec.MinHeight,
+		MaxHeight: rec.MaxHeight,
+		Root:      rec.Root,
+		Proof:     rec.Proofs[height],
+	})
+}
+
+func (service *AnchorService) batchProofsFilePath() string {
+	if service.dataDir == "" {
+		return ""
+	}
+	return filepath.Join(service.dataDir, anchorProofsFile)
+}
+
+func (service *AnchorService) saveBatchProofs(path string) error {
+	service.batchMu.Lock()
+	records := make(map[uint32]*BatchAnchorRecord, len(service.batchProofs))
+	for height, rec := range service.batchProofs {
+		records[height] = rec
+	}
+	service.batchMu.Unlock()
+
+	encoded, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+func (service *AnchorService) loadBatchProofs(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var records map[uint32]*BatchAnchorRecord
+	if err := json.Unmarshal(raw, &records); err != nil {
+		return err
+	}
+
+	service.batchMu.Lock()
+	service.batchProofs = records
+	service.batchMu.Unlock()
+	return nil
+}
+
+func (service *AnchorService) pendingBatchFilePath() string {
+	if service.dataDir == "" {
+		return ""
+	}
+	return filepath.Join(service.dataDir, pendingBatchFile)
+}
+
+// savePendingBatch flushes whatever is still accumulating in pendingBatch
+// to disk, so a batch that hasn't reached batchSize or batchInterval yet
+// isn't lost across a restart the way it would be if only flushed batches
+// were persisted.
+func (service *AnchorService) savePendingBatch(path string) error {
+	service.batchMu.Lock()
+	batch := service.pendingBatch
+	service.batchMu.Unlock()
+
+	encoded, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+func (service *AnchorService) loadPendingBatch(path string) error {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var batch []common.DirectoryBlockAnchorInfo
+	if err := json.Unmarshal(raw, &batch); err != nil {
+		return err
+	}
+
+	service.batchMu.Lock()
+	service.pendingBatch = append(service.pendingBatch, batch...)
+	service.batchMu.Unlock()
+	return nil
+}
@@ -0,0 +1,109 @@
+package anchor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+// verifyProof recombines a leaf's hash with its proof's siblings, bottom up,
+// and reports whether the result matches root - the same check a downstream
+// verifier would run against GetAnchorProof's output.
+func verifyProof(leaf []byte, proof *MerkleProof, root []byte) bool {
+	sum := sha256.Sum256(leaf)
+	current := sum[:]
+	index := proof.LeafIndex
+
+	for _, sibling := range proof.Siblings {
+		var combined []byte
+		if index%2 == 0 {
+			combined = append(append([]byte{}, current...), sibling...)
+		} else {
+			combined = append(append([]byte{}, sibling...), current...)
+		}
+		next := sha256.Sum256(combined)
+		current = next[:]
+		index /= 2
+	}
+
+	return bytes.Equal(current, root)
+}
+
+func TestBuildMerkleTreeAndProofFor(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 5, 7, 8} {
+		leaves := make([][]byte, n)
+		for i := range leaves {
+			leaves[i] = []byte{byte(i), byte(i + 1), byte(i + 2)}
+		}
+
+		root, levels := buildMerkleTree(leaves)
+
+		for i, leaf := range leaves {
+			proof := proofFor(i, levels)
+			if proof.LeafIndex != i {
+				t.Fatalf("n=%d: proofFor(%d) leaf index = %d, want %d", n, i, proof.LeafIndex, i)
+			}
+			if !verifyProof(leaf, proof, root) {
+				t.Errorf("n=%d: proof for leaf %d does not resolve to root", n, i)
+			}
+		}
+	}
+}
+
+func TestBuildMerkleTreeSingleLeaf(t *testing.T) {
+	leaf := []byte("only leaf")
+	root, levels := buildMerkleTree([][]byte{leaf})
+
+	sum := sha256.Sum256(leaf)
+	if !bytes.Equal(root, sum[:]) {
+		t.Fatalf("single-leaf root should be the leaf's hash")
+	}
+
+	proof := proofFor(0, levels)
+	if len(proof.Siblings) != 0 {
+		t.Fatalf("single-leaf proof should have no siblings, got %d", len(proof.Siblings))
+	}
+}
+
+func TestPrependBlockHeightAndBatchHeaderShareHeightPacking(t *testing.T) {
+	hash := []byte("some 32 byte directory block hash..")
+
+	single, err := PrependBlockHeight(12345, hash)
+	if err != nil {
+		t.Fatalf("PrependBlockHeight: %v", err)
+	}
+
+	wantHeight, err := packHeight(12345)
+	if err != nil {
+		t.Fatalf("packHeight: %v", err)
+	}
+
+	if !bytes.Equal(single[:2], []byte{'F', 'a'}) {
+		t.Fatalf("PrependBlockHeight missing 'Fa' marker")
+	}
+	if !bytes.Equal(single[2:8], wantHeight) {
+		t.Fatalf("PrependBlockHeight height bytes = %x, want %x", single[2:8], wantHeight)
+	}
+	if !bytes.Equal(single[8:], hash) {
+		t.Fatalf("PrependBlockHeight did not preserve the hash payload")
+	}
+
+	root := []byte("a fake merkle root..............")
+	batch, err := PrependBatchHeader(100, 200, root)
+	if err != nil {
+		t.Fatalf("PrependBatchHeader: %v", err)
+	}
+
+	minBytes, _ := packHeight(100)
+	maxBytes, _ := packHeight(200)
+
+	if !bytes.Equal(batch[:2], []byte{'F', 'a'}) {
+		t.Fatalf("PrependBatchHeader missing 'Fa' marker")
+	}
+	if !bytes.Equal(batch[2:8], minBytes) || !bytes.Equal(batch[8:14], maxBytes) {
+		t.Fatalf("PrependBatchHeader did not pack min/max heights via packHeight")
+	}
+	if !bytes.Equal(batch[14:], root) {
+		t.Fatalf("PrependBatchHeader did not preserve the root payload")
+	}
+}
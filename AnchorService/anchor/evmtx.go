@@ -0,0 +1,59 @@
+package anchor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const defaultAnchorTxTimeout = 30 * time.Second
+
+// sendEVMTransaction signs and broadcasts a zero-value transaction from the
+// address derived from key to `to`, carrying data as its payload - a plain
+// transfer's data field for AnchorETH, a packed contract call for
+// AnchorEVM. Both Ethereum-family backends need nothing fancier than "sign
+// this data and get it mined," so they share this instead of each
+// reimplementing nonce/gas/chain-id plumbing.
+func sendEVMTransaction(client *ethclient.Client, key *ecdsa.PrivateKey, from, to ethcommon.Address, data []byte) (ethcommon.Hash, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultAnchorTxTimeout)
+	defer cancel()
+
+	nonce, err := client.PendingNonceAt(ctx, from)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("fetching nonce: %w", err)
+	}
+
+	gasLimit, err := client.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Data: data})
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("estimating gas: %w", err)
+	}
+
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("suggesting gas price: %w", err)
+	}
+
+	chainID, err := client.NetworkID(ctx)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("fetching chain id: %w", err)
+	}
+
+	tx := types.NewTransaction(nonce, to, big.NewInt(0), gasLimit, gasPrice, data)
+	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), key)
+	if err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("signing anchor transaction: %w", err)
+	}
+
+	if err := client.SendTransaction(ctx, signedTx); err != nil {
+		return ethcommon.Hash{}, fmt.Errorf("broadcasting anchor transaction: %w", err)
+	}
+
+	return signedTx.Hash(), nil
+}
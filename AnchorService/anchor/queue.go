@@ -0,0 +1,161 @@
+package anchor
+
+import (
+	"AnchorService/common"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkerCount = 4
+	defaultMaxAttempts = 12
+
+	initialBackoff = 30 * time.Second
+	maxBackoff     = 30 * time.Minute
+
+	anchorQueueFile = "anchor_queue.json"
+)
+
+// pendingAnchor is one directory block waiting to be anchored, along with
+// enough retry bookkeeping to survive a restart.
+type pendingAnchor struct {
+	Info        common.DirectoryBlockAnchorInfo `json:"info"`
+	Attempts    int                             `json:"attempts"`
+	NextAttempt time.Time                       `json:"next_attempt"`
+}
+
+// anchorQueue is the pending-work queue for the anchor service, keyed by
+// directory block height so a late retry for a height never duplicates
+// work already queued for it.
+type anchorQueue struct {
+	mu    sync.Mutex
+	items map[uint32]*pendingAnchor
+}
+
+func newAnchorQueue() *anchorQueue {
+	return &anchorQueue{items: make(map[uint32]*pendingAnchor)}
+}
+
+// upsert admits a freshly-seen DirectoryBlockAnchorInfo, ready to anchor
+// immediately.
+func (q *anchorQueue) upsert(info common.DirectoryBlockAnchorInfo) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items[info.DBHeight] = &pendingAnchor{
+		Info:        info,
+		NextAttempt: time.Now(),
+	}
+}
+
+// requeue re-admits a DirectoryBlockAnchorInfo that just failed to anchor,
+// scheduling its next attempt with exponential backoff. It reports dropped
+// = true once maxAttempts is exceeded, in which case the item is removed
+// from the queue instead of being rescheduled.
+func (q *anchorQueue) requeue(info common.DirectoryBlockAnchorInfo, maxAttempts int) (dropped bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	p, ok := q.items[info.DBHeight]
+	if !ok {
+		p = &pendingAnchor{Info: info}
+		q.items[info.DBHeight] = p
+	}
+
+	p.Attempts++
+	if p.Attempts > maxAttempts {
+		delete(q.items, info.DBHeight)
+		return true
+	}
+
+	p.NextAttempt = time.Now().Add(backoffFor(p.Attempts))
+	return false
+}
+
+func backoffFor(attempt int) time.Duration {
+	backoff := initialBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return backoff
+}
+
+// ready returns, and removes from the queue, every item whose NextAttempt
+// has elapsed. Callers are expected to hand these to the worker pool and
+// call requeue/upsert again if they fail.
+func (q *anchorQueue) ready(now time.Time) []*pendingAnchor {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []*pendingAnchor
+	for height, p := range q.items {
+		if !p.NextAttempt.After(now) {
+			due = append(due, p)
+			delete(q.items, height)
+		}
+	}
+	return due
+}
+
+// reinsert puts p directly back in the queue, preserving its Attempts/
+// NextAttempt bookkeeping. It's for dispatch's ticker branch: if handing p
+// to a worker races with shutdown, reinsert puts it back in q.items so
+// Stop's snapshot sees it and persists it instead of losing it.
+func (q *anchorQueue) reinsert(p *pendingAnchor) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items[p.Info.DBHeight] = p
+}
+
+// snapshot returns every item still outstanding, for persistence to disk.
+func (q *anchorQueue) snapshot() []*pendingAnchor {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	items := make([]*pendingAnchor, 0, len(q.items))
+	for _, p := range q.items {
+		items = append(items, p)
+	}
+	return items
+}
+
+// load seeds the queue from a previously persisted snapshot.
+func (q *anchorQueue) load(items []*pendingAnchor) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, p := range items {
+		q.items[p.Info.DBHeight] = p
+	}
+}
+
+func saveQueueFile(path string, items []*pendingAnchor) error {
+	encoded, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+func loadQueueFile(path string) ([]*pendingAnchor, error) {
+	raw, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*pendingAnchor
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,84 @@
+package anchor
+
+import (
+	"testing"
+	"time"
+
+	"AnchorService/common"
+)
+
+func TestBackoffFor(t *testing.T) {
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, 1 * time.Minute},
+		{3, 2 * time.Minute},
+		{4, 4 * time.Minute},
+		{10, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, c := range cases {
+		if got := backoffFor(c.attempt); got != c.want {
+			t.Errorf("backoffFor(%d) = %s, want %s", c.attempt, got, c.want)
+		}
+	}
+}
+
+func TestAnchorQueueUpsertAndReady(t *testing.T) {
+	q := newAnchorQueue()
+	q.upsert(common.DirectoryBlockAnchorInfo{DBHeight: 10})
+
+	if ready := q.ready(time.Now().Add(time.Hour)); len(ready) != 1 {
+		t.Fatalf("expected 1 ready item, got %d", len(ready))
+	}
+
+	// ready() removes what it returns.
+	if ready := q.ready(time.Now().Add(time.Hour)); len(ready) != 0 {
+		t.Fatalf("expected queue to be drained, got %d items", len(ready))
+	}
+}
+
+func TestAnchorQueueRequeueBackoffAndDrop(t *testing.T) {
+	q := newAnchorQueue()
+	info := common.DirectoryBlockAnchorInfo{DBHeight: 42}
+
+	if dropped := q.requeue(info, 2); dropped {
+		t.Fatalf("first failure should not be dropped")
+	}
+	if ready := q.ready(time.Now()); len(ready) != 0 {
+		t.Fatalf("item scheduled with backoff should not be ready immediately, got %d", len(ready))
+	}
+	if ready := q.ready(time.Now().Add(time.Hour)); len(ready) != 1 {
+		t.Fatalf("item should be ready once its backoff has elapsed, got %d", len(ready))
+	}
+
+	if dropped := q.requeue(info, 2); dropped {
+		t.Fatalf("second failure (attempt 2 of max 2) should not be dropped")
+	}
+	if dropped := q.requeue(info, 2); !dropped {
+		t.Fatalf("third failure (attempt 3 of max 2) should be dropped")
+	}
+	if ready := q.ready(time.Now().Add(time.Hour)); len(ready) != 0 {
+		t.Fatalf("dropped item should not resurface, got %d items", len(ready))
+	}
+}
+
+func TestAnchorQueueSnapshotAndLoad(t *testing.T) {
+	q := newAnchorQueue()
+	q.upsert(common.DirectoryBlockAnchorInfo{DBHeight: 1})
+	q.upsert(common.DirectoryBlockAnchorInfo{DBHeight: 2})
+
+	snapshot := q.snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("expected 2 items in snapshot, got %d", len(snapshot))
+	}
+
+	restored := newAnchorQueue()
+	restored.load(snapshot)
+	if ready := restored.ready(time.Now().Add(time.Hour)); len(ready) != 2 {
+		t.Fatalf("expected 2 items after loading snapshot, got %d", len(ready))
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"AnchorService/common"
 	"AnchorService/util"
 	"bytes"
-	"encoding/binary"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,28 +15,78 @@ import (
 	"github.com/FactomProject/factomd/common/primitives"
 	"io/ioutil"
 	"net/http"
-	"os"
-	"syscall"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
 var log = util.AnchorLogger
 
+// ErrFactomdUnauthorized is returned by postV2 when factomd rejects the
+// configured FactomdUser/FactomdPassword with a 401.
+var ErrFactomdUnauthorized = errors.New("factomd rejected the configured username/password")
+
+const defaultFactomdTimeout = 30 * time.Second
+
+const (
+	postV2MaxAttempts = 3
+	postV2RetryDelay  = 2 * time.Second
+)
+
+// FactomdRPCError wraps a factomd JSON-RPC error response (e.g. "repeated
+// commit", insufficient entry credit balance) returned by postV2. It is a
+// distinct type from a transport failure so callers like postV2's own
+// retry loop, and bootstrapAnchorChain's EC-balance check, can tell "the
+// request reached factomd and factomd rejected it" apart from "the
+// request never got there."
+type FactomdRPCError struct {
+	Method string
+	Err    error
+}
+
+func (e *FactomdRPCError) Error() string {
+	return fmt.Sprintf("factomd rejected %s: %s", e.Method, e.Err)
+}
+
+func (e *FactomdRPCError) Unwrap() error {
+	return e.Err
+}
+
 type Anchor interface {
 	PlaceAnchor(msg common.DirectoryBlockAnchorInfo)
 }
 
 type AnchorService struct {
-	DoAnchor      Anchor
-	serverECKey   *factom.ECAddress
-	sigKey        *primitives.PrivateKey
-	factomserver  string
-	anchorChainID *common.Hash
-	DirBlockMsg   chan common.DirectoryBlockAnchorInfo
-	AnchorFail    chan bool
+	DoAnchor       Anchor
+	serverECKey    *factom.ECAddress
+	sigKey         *primitives.PrivateKey
+	factomserver   string
+	factomdUser    string
+	factomdPass    string
+	httpClient     *http.Client
+	factomdTimeout time.Duration
+	anchorChainID  *common.Hash
+	DirBlockMsg    chan common.DirectoryBlockAnchorInfo
+	AnchorFail     chan common.DirectoryBlockAnchorInfo
+
+	queue       *anchorQueue
+	dataDir     string
+	workerCount int
+	maxAttempts int
+
+	batchSize     int
+	batchInterval time.Duration
+	batchMu       sync.Mutex
+	pendingBatch  []common.DirectoryBlockAnchorInfo
+	batchProofs   map[uint32]*BatchAnchorRecord
+	batchFlushCh  chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
-func NewAnchorService(DirBlockMsg chan common.DirectoryBlockAnchorInfo, AnchorFail chan bool) *AnchorService {
+func NewAnchorService(DirBlockMsg chan common.DirectoryBlockAnchorInfo, AnchorFail chan common.DirectoryBlockAnchorInfo) *AnchorService {
 	cfg := util.ReadConfig()
 	service := new(AnchorService)
 	var err error
@@ -48,66 +100,348 @@ func NewAnchorService(DirBlockMsg chan common.DirectoryBlockAnchorInfo, AnchorFa
 	if err != nil {
 		panic("Cannot parse signature key Key from configuration file: " + err.Error())
 	}
-	anchorChainID, err := common.HexToHash(cfg.Anchor.AnchorChainID)
-
-	if err != nil || anchorChainID == nil {
-		panic("Cannot parse Server AnchorChainID from configuration file: " + err.Error())
-	}
 
 	service.factomserver = cfg.App.FactomAddr
+	service.factomdUser = cfg.Anchor.FactomdUser
+	service.factomdPass = cfg.Anchor.FactomdPassword
+	service.factomdTimeout = factomdRequestTimeout(cfg)
 	log.Info("FactomAddress ", "server addr", service.factomserver)
 
+	service.httpClient, err = newFactomdHTTPClient(cfg)
+	if err != nil {
+		panic("Cannot build factomd HTTP client from configuration file: " + err.Error())
+	}
+
+	// Point the factom SDK's own package-level client at the same server
+	// and credentials postV2 uses, so SDK calls that don't go through
+	// postV2 (factom.GetChainHead during bootstrap, for one) still talk to
+	// the configured factomd instead of the SDK's built-in default.
+	configureFactomSDK(cfg)
+
+	anchorChainID, err := bootstrapAnchorChain(cfg, service)
+	if err != nil {
+		panic("Cannot set up the anchor chain from configuration file: " + err.Error())
+	}
+
 	service.anchorChainID = anchorChainID
 	service.DirBlockMsg = DirBlockMsg
 	service.AnchorFail = AnchorFail
+	service.queue = newAnchorQueue()
+	service.dataDir = cfg.Anchor.DataDir
+	service.workerCount = cfg.Anchor.WorkerCount
+	if service.workerCount <= 0 {
+		service.workerCount = defaultWorkerCount
+	}
+	service.maxAttempts = cfg.Anchor.MaxAttempts
+	if service.maxAttempts <= 0 {
+		service.maxAttempts = defaultMaxAttempts
+	}
+
+	service.batchSize = cfg.Anchor.BatchSize
+	service.batchInterval = time.Duration(cfg.Anchor.BatchInterval) * time.Second
+	if service.batchSize > 0 && service.batchInterval <= 0 {
+		service.batchInterval = defaultBatchInterval
+	}
+
+	backend, ok := Lookup(cfg.App.AnchorTo)
+	if !ok {
+		log.Crit("Not support this kind of anchor, check your configuration file", "anchorTo", cfg.App.AnchorTo)
+		return nil
+	}
+
+	log.Info("anchor to", "backend", backend.Name())
+	if err := backend.Init(&cfg.Anchor.Backend, service); err != nil {
+		log.Crit("Error on init anchor backend", "backend", backend.Name(), "error", err)
+		return nil
+	}
+
+	service.DoAnchor = backend
+	return service
+}
+
+// factomdRequestTimeout returns the configured Anchor.FactomdTimeout,
+// falling back to defaultFactomdTimeout when it's unset - the one place
+// this gets computed, so newFactomdHTTPClient's *http.Client (per request)
+// and AnchorService.factomdTimeout's contexts use the same value. Each of
+// those contexts spans a whole commitAndRevealEntry or bootstrapAnchorChain
+// call (commit, retries, the 2s sleep and reveal), not a single request.
+func factomdRequestTimeout(cfg *common.Config) time.Duration {
+	if cfg.Anchor.FactomdTimeout > 0 {
+		return time.Duration(cfg.Anchor.FactomdTimeout) * time.Second
+	}
+	return defaultFactomdTimeout
+}
+
+// newFactomdHTTPClient builds the *http.Client used for every factomd /v2
+// request, wiring up the optional TLS transport and per-request timeout
+// from the Anchor config section.
+func newFactomdHTTPClient(cfg *common.Config) (*http.Client, error) {
+	timeout := factomdRequestTimeout(cfg)
 
-	if cfg.App.AnchorTo == 0 {
-		log.Info("anchor to btc")
-		btc := NewAnchorBTC()
-		err := btc.InitRPCClient()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Anchor.FactomdTLS {
+		tlsConfig := &tls.Config{}
+
+		if cfg.Anchor.FactomdTLSCAPath != "" {
+			caCert, err := ioutil.ReadFile(cfg.Anchor.FactomdTLSCAPath)
+			if err != nil {
+				return nil, fmt.Errorf("reading FactomdTLS CA cert: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caCert) {
+				return nil, errors.New("FactomdTLS CA cert is not valid PEM")
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		if cfg.Anchor.FactomdTLSClientCertPath != "" && cfg.Anchor.FactomdTLSClientKeyPath != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.Anchor.FactomdTLSClientCertPath, cfg.Anchor.FactomdTLSClientKeyPath)
+			if err != nil {
+				return nil, fmt.Errorf("loading FactomdTLS client cert: %w", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// configureFactomSDK points the factom SDK's package-level factomd client
+// at cfg.App.FactomAddr and the configured FactomdUser/FactomdPassword/TLS
+// settings. The SDK keeps its own global client for calls like
+// factom.GetChainHead that don't go through postV2, so without this those
+// calls would silently hit the SDK's default server/credentials instead of
+// the ones configured here.
+func configureFactomSDK(cfg *common.Config) {
+	factom.SetFactomdServer(cfg.App.FactomAddr)
+	if cfg.Anchor.FactomdUser != "" || cfg.Anchor.FactomdPassword != "" {
+		factom.SetFactomdRpcConfig(cfg.Anchor.FactomdUser, cfg.Anchor.FactomdPassword)
+	}
+	if cfg.Anchor.FactomdTLS {
+		factom.SetFactomdTLSEnable(true)
+		if cfg.Anchor.FactomdTLSCAPath != "" {
+			factom.SetFactomdTLSCert(cfg.Anchor.FactomdTLSCAPath)
+		}
+	}
+}
+
+// Start launches the anchor service's dispatch loop and worker pool in the
+// background and returns immediately. It follows the node.Service
+// convention: the caller owns ctx and stops the service by either
+// cancelling ctx or calling Stop.
+func (service *AnchorService) Start(ctx context.Context) error {
+	log.Info("Start Anchor service...")
+
+	service.ctx, service.cancel = context.WithCancel(ctx)
+
+	if path := service.queueFilePath(); path != "" {
+		items, err := loadQueueFile(path)
 		if err != nil {
-			log.Crit("Error on init RPC :", "error", err)
+			log.Error("could not load persisted anchor queue, starting empty", "path", path, "error", err)
+		} else if len(items) > 0 {
+			service.queue.load(items)
+			log.Info("resumed anchor queue from disk", "path", path, "pending", len(items))
+		}
+	}
+
+	if path := service.batchProofsFilePath(); path != "" {
+		if err := service.loadBatchProofs(path); err != nil {
+			log.Error("could not load persisted anchor batch proofs", "path", path, "error", err)
 		}
+	}
 
-		service.DoAnchor = btc
-		btc.service = service
-		return service
-	} else if cfg.App.AnchorTo == 1 {
-		log.Info("anchor to eth")
-		eth := NewAnchorETH()
-		eth.InitEthClient()
-		service.DoAnchor = eth
-		eth.service = service
+	if path := service.pendingBatchFilePath(); path != "" {
+		if err := service.loadPendingBatch(path); err != nil {
+			log.Error("could not load persisted pending anchor batch", "path", path, "error", err)
+		}
+	}
 
-		return service
-	} else {
-		log.Crit("Not support this kind of anchor, check your configuration file")
+	work := make(chan common.DirectoryBlockAnchorInfo)
+
+	for i := 0; i < service.workerCount; i++ {
+		service.wg.Add(1)
+		go service.worker(work)
+	}
+
+	if service.batchSize > 0 {
+		// Batch flushes (PlaceAnchor + commit/reveal, including its 2s
+		// stabilization sleep) run on their own goroutine, never inline on
+		// dispatch, so a flush in progress never blocks DirBlockMsg/
+		// AnchorFail/queue processing. Using one dedicated worker (rather
+		// than one goroutine per flush) keeps flushes serialized.
+		service.batchFlushCh = make(chan struct{}, 1)
+		service.wg.Add(1)
+		go service.batchWorker()
 	}
 
+	service.wg.Add(1)
+	go service.dispatch(work)
+
 	return nil
 }
 
-func (service *AnchorService) Start() {
-	log.Info("Start Anchor service...")
+// batchWorker serializes batch flushes onto their own goroutine so the
+// network I/O in flushBatch never blocks dispatch's select loop. It drains
+// one final batch on shutdown before returning.
+func (service *AnchorService) batchWorker() {
+	defer service.wg.Done()
 
-	failedTime := 0
 	for {
 		select {
+		case <-service.ctx.Done():
+			service.flushBatch()
+			return
+		case <-service.batchFlushCh:
+			service.flushBatch()
+		}
+	}
+}
+
+// signalFlush wakes batchWorker without blocking dispatch - if a flush is
+// already pending or in flight, the signal is dropped since that flush
+// will pick up everything accumulated so far anyway.
+func (service *AnchorService) signalFlush() {
+	select {
+	case service.batchFlushCh <- struct{}{}:
+	default:
+	}
+}
+
+// Stop cancels the dispatch loop and worker pool, waits for them to drain,
+// and flushes any outstanding work to disk so a restart can pick up exactly
+// where it left off.
+func (service *AnchorService) Stop() error {
+	if service.cancel == nil {
+		return nil
+	}
+	service.cancel()
+	service.wg.Wait()
+
+	if path := service.queueFilePath(); path != "" {
+		if err := saveQueueFile(path, service.queue.snapshot()); err != nil {
+			log.Error("could not flush anchor queue to disk", "path", path, "error", err)
+			return err
+		}
+	}
+
+	if path := service.pendingBatchFilePath(); path != "" {
+		if err := service.savePendingBatch(path); err != nil {
+			log.Error("could not flush pending anchor batch to disk", "path", path, "error", err)
+			return err
+		}
+	}
+
+	if backend, ok := service.DoAnchor.(Backend); ok {
+		if err := backend.Close(); err != nil {
+			log.Error("error closing anchor backend", "backend", backend.Name(), "error", err)
+			return err
+		}
+	}
+
+	log.Info("Anchor service stopped")
+	return nil
+}
+
+// dispatch owns the AnchorService's queue: it admits new work from
+// DirBlockMsg, re-queues failures reported on AnchorFail with exponential
+// backoff, and periodically hands ready work to the worker pool. When
+// cfg.Anchor.BatchSize is set, incoming blocks are accumulated into
+// pendingBatch instead and flushed as one Merkle-batched anchor record
+// whenever the batch fills up or batchInterval elapses.
+func (service *AnchorService) dispatch(work chan<- common.DirectoryBlockAnchorInfo) {
+	defer service.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var batchTicker *time.Ticker
+	var batchTick <-chan time.Time
+	if service.batchSize > 0 {
+		batchTicker = time.NewTicker(service.batchInterval)
+		batchTick = batchTicker.C
+		defer batchTicker.Stop()
+	}
+
+	for {
+		select {
+		case <-service.ctx.Done():
+			return
 		case anchorMsg := <-service.DirBlockMsg:
 			log.Info("Got anchor msg: ", "msg", anchorMsg)
-			go service.DoAnchor.PlaceAnchor(anchorMsg)
-		case _ = <-service.AnchorFail:
-			failedTime++
-			log.Error("anchor failed", "time", failedTime)
-			if failedTime >= 10 {
-				log.Error("more than 10 times fail to anchor, just quit job")
-				p, _ := os.FindProcess(os.Getpid())
-				p.Signal(syscall.SIGQUIT)
+			if service.batchSize > 0 {
+				if service.addToBatch(anchorMsg) {
+					service.signalFlush()
+				}
+				continue
+			}
+			service.queue.upsert(anchorMsg)
+		case failedMsg := <-service.AnchorFail:
+			if dropped := service.queue.requeue(failedMsg, service.maxAttempts); dropped {
+				log.Error("anchor exceeded max attempts, dropping", "height", failedMsg.DBHeight)
+			} else {
+				log.Error("anchor failed, scheduled for retry", "height", failedMsg.DBHeight)
 			}
+		case <-batchTick:
+			service.signalFlush()
+		case <-ticker.C:
+			due := service.queue.ready(time.Now())
+			for i, p := range due {
+				select {
+				case work <- p.Info:
+				case <-service.ctx.Done():
+					// due items already came out of q.items via ready();
+					// without putting them back, a shutdown racing this
+					// handoff would drop them before Stop's snapshot ever
+					// sees them.
+					for _, unsent := range due[i:] {
+						service.queue.reinsert(unsent)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// worker calls DoAnchor.PlaceAnchor for whatever the dispatch loop hands
+// it, giving the service bounded concurrency across the N workers started
+// by Start. It frames the raw directory block hash via PrependBlockHeight
+// before handing it to the backend, the same way flushBatch frames a
+// batch's root via PrependBatchHeader before its own PlaceAnchor call - so
+// every backend's PlaceAnchor can assume DBMerkleRoot is already the exact
+// bytes to anchor, single-block or batched.
+func (service *AnchorService) worker(work <-chan common.DirectoryBlockAnchorInfo) {
+	defer service.wg.Done()
+
+	for {
+		select {
+		case <-service.ctx.Done():
+			return
+		case anchorMsg := <-work:
+			payload, err := PrependBlockHeight(anchorMsg.DBHeight, anchorMsg.DBMerkleRoot)
+			if err != nil {
+				log.Error("could not build anchor payload", "height", anchorMsg.DBHeight, "error", err)
+				continue
+			}
+			anchorMsg.DBMerkleRoot = payload
+			service.DoAnchor.PlaceAnchor(anchorMsg)
 		}
 	}
 }
 
+func (service *AnchorService) queueFilePath() string {
+	if service.dataDir == "" {
+		return ""
+	}
+	return filepath.Join(service.dataDir, anchorQueueFile)
+}
+
 func NewEntry(chainid string, external, content []byte) *factom.Entry {
 	entry := new(factom.Entry)
 	entry.ChainID = chainid
@@ -119,107 +453,155 @@ func NewEntry(chainid string, external, content []byte) *factom.Entry {
 	return entry
 }
 
-func (anchor *AnchorService) submitEntryToAnchorChain(anchorRec *anchor.AnchorRecord) error {
-	raw, sign, err := anchorRec.MarshalAndSignV2(anchor.sigKey)
-	if err != nil {
-		return err
-	}
+// postV2 posts a pre-composed commit/reveal body to the factomd /v2
+// endpoint, attaching basic auth and a request timeout/context, retrying
+// transport-level failures a bounded number of times, and parsing the
+// JSON-RPC envelope that comes back for a factomd-reported error. method
+// is the factomd RPC method being called (e.g. "commit-entry"), used only
+// for logging and errors.
+func (service *AnchorService) postV2(ctx context.Context, method string, body interface{}) (*util.JSON2Response, error) {
+	var lastErr error
+
+	for attempt := 1; attempt <= postV2MaxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(postV2RetryDelay):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			log.Info("retrying factomd request", "method", method, "attempt", attempt)
+		}
 
-	newentry := NewEntry(anchor.anchorChainID.String(), sign, raw)
-	commit, err := factom.ComposeEntryCommit(newentry, anchor.serverECKey)
-	if err != nil {
-		return err
+		r, err := service.postV2Once(ctx, method, body)
+		if err == nil {
+			return r, nil
+		}
+
+		// Auth failures and factomd-reported JSON-RPC errors are final -
+		// retrying won't make the password right or the commit succeed.
+		if errors.Is(err, ErrFactomdUnauthorized) {
+			return nil, err
+		}
+		var rpcErr *FactomdRPCError
+		if errors.As(err, &rpcErr) {
+			return nil, err
+		}
+
+		lastErr = err
+		log.Error("postV2 request failed", "method", method, "attempt", attempt, "error", err)
 	}
 
-	commitBody, err := util.EncodeJSON(commit)
+	return nil, lastErr
+}
 
+// postV2Once is a single, non-retrying attempt at postV2.
+func (service *AnchorService) postV2Once(ctx context.Context, method string, body interface{}) (*util.JSON2Response, error) {
+	encoded, err := util.EncodeJSON(body)
 	if err != nil {
-		log.Error("Encode error ", commitBody)
-		return err
+		return nil, err
 	}
 
-	httpClient := http.DefaultClient
-	log.Info("do commit ", "commit", string(commitBody))
-	re, err := http.NewRequest("POST", fmt.Sprintf("http://%s/v2", anchor.factomserver), bytes.NewBuffer(commitBody))
-
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("http://%s/v2", service.factomserver), bytes.NewBuffer(encoded))
 	if err != nil {
-		log.Error("error happened, for entry commit ", err)
-		return err
+		return nil, err
+	}
+	if service.factomdUser != "" || service.factomdPass != "" {
+		req.SetBasicAuth(service.factomdUser, service.factomdPass)
 	}
 
-	resp, err := httpClient.Do(re)
+	resp, err := service.httpClient.Do(req)
 	if err != nil {
-		log.Error("Error for http request ", err)
-		return err
+		return nil, err
 	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode == http.StatusUnauthorized {
-		log.Error("Factomd username/password incorrect.  Edit factomd.conf or\ncall factom-cli with -factomduser=<user> -factomdpassword=<pass>")
+		log.Error("Factomd username/password incorrect.  Edit factomd.conf or\ncall factom-cli with -factomduser=<user> -factomdpassword=<pass>", "method", method)
+		return nil, ErrFactomdUnauthorized
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 
 	r := util.NewJSON2Response()
-	if err := json.Unmarshal(body, r); err != nil {
-		log.Error("Error on http request parse body", err)
+	if err := json.Unmarshal(raw, r); err != nil {
+		return nil, fmt.Errorf("parsing factomd response to %s: %w", method, err)
 	}
 
-	log.Debug("Got response for commit entry ", "entry", r)
-	time.Sleep(2000)
-	rev, err := factom.ComposeEntryReveal(newentry)
-	if err != nil {
-		log.Info("Got error on entry compose ", err)
+	if r.Error != nil {
+		return nil, &FactomdRPCError{Method: method, Err: errors.New(r.Error.Message)}
 	}
 
-	revBody, err := util.EncodeJSON(rev)
+	log.Debug("Got response from factomd", "method", method, "response", r)
+	return r, nil
+}
 
-	log.Info("Do reveal ", "body", string(revBody))
+// submitEntryToAnchorChain is the per-dir-block code path: it signs and
+// wraps a single anchor.AnchorRecord as one Factom entry and commits +
+// reveals it. submitBatchToAnchorChain is its batch-mode sibling; both
+// funnel through commitAndRevealEntry for the actual factomd calls.
+func (service *AnchorService) submitEntryToAnchorChain(anchorRec *anchor.AnchorRecord) error {
+	raw, sign, err := anchorRec.MarshalAndSignV2(service.sigKey)
 	if err != nil {
-		log.Error("Encode error ", revBody)
+		return err
 	}
 
-	re, err = http.NewRequest("POST", fmt.Sprintf("http://%s/v2", anchor.factomserver), bytes.NewBuffer(revBody))
+	newentry := NewEntry(service.anchorChainID.String(), sign, raw)
+	return service.commitAndRevealEntry(newentry)
+}
 
+// commitAndRevealEntry composes and posts the commit, sleeps the usual
+// stabilization window, then composes and posts the reveal - the same
+// two-step dance factomd entries always need, shared by both the
+// per-dir-block and batched anchor record code paths.
+func (service *AnchorService) commitAndRevealEntry(newentry *factom.Entry) error {
+	commit, err := factom.ComposeEntryCommit(newentry, service.serverECKey)
 	if err != nil {
-		log.Error("error happened, for entry revl ", err)
 		return err
 	}
 
-	resp2, err := httpClient.Do(re)
-	if err != nil {
-		log.Error("Error for http request ", err)
+	ctx, cancel := context.WithTimeout(context.Background(), service.factomdTimeout)
+	defer cancel()
+
+	log.Info("do commit ", "commit", commit)
+	if _, err := service.postV2(ctx, "commit-entry", commit); err != nil {
+		log.Error("error happened, for entry commit ", err)
 		return err
 	}
 
-	if resp2.StatusCode == http.StatusUnauthorized {
-		log.Error("Factomd username/password incorrect.  Edit factomd.conf or\ncall factom-cli with -factomduser=<user> -factomdpassword=<pass>")
-	}
-	defer resp2.Body.Close()
+	time.Sleep(2 * time.Second)
 
-	body, err = ioutil.ReadAll(resp2.Body)
+	rev, err := factom.ComposeEntryReveal(newentry)
+	if err != nil {
+		log.Info("Got error on entry compose ", err)
+		return err
+	}
 
-	r = util.NewJSON2Response()
-	if err := json.Unmarshal(body, r); err != nil {
-		log.Error("Error on http request parse body", err)
+	log.Info("Do reveal ", "body", rev)
+	if _, err := service.postV2(ctx, "reveal-entry", rev); err != nil {
+		log.Error("error happened, for entry reveal ", err)
+		return err
 	}
 
-	log.Debug("Got response for reveal", "response", r)
 	return nil
 }
 
+// PrependBlockHeight builds the 'F','a' + 6-byte-height leaf prefix used for
+// a single directory block. PrependBatchHeader (batch.go) is the same
+// prefix generalized to a height range; both pack their height fields via
+// the shared packHeight so there's one height-packing implementation.
 func PrependBlockHeight(height uint32, hash []byte) ([]byte, error) {
 	// dir block genesis block height starts with 0, for now
 	// similar to bitcoin genesis block
-	h := uint64(height)
-	if 0xFFFFFFFFFFFF&h != h {
-		return nil, errors.New("bad block height")
+	heightBytes, err := packHeight(height)
+	if err != nil {
+		return nil, err
 	}
 
 	header := []byte{'F', 'a'}
-	big := make([]byte, 8)
-	binary.BigEndian.PutUint64(big, h) //height)
-
-	newdata := append(big[2:8], hash...)
+	newdata := append(append([]byte{}, heightBytes...), hash...)
 	newdata = append(header, newdata...)
 	return newdata, nil
 }